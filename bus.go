@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// pollFd mirrors struct pollfd from <poll.h>, used to drive our own
+// poll(2) wait instead of blocking the whole goroutine in a syscall.
+type pollFd struct {
+	Fd      int32
+	Events  int16
+	Revents int16
+}
+
+const (
+	pollIn  = 0x0001
+	pollOut = 0x0004
+)
+
+// pollFile wraps a non-blocking *os.File with context-aware Read/Write:
+// each call either completes immediately or waits on poll(2) for the fd to
+// become ready, bounded by ctx's deadline, instead of blocking forever.
+type pollFile struct {
+	f  *os.File
+	rc syscall.RawConn
+}
+
+func newPollFile(f *os.File) (*pollFile, error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var setErr error
+	if ctlErr := rc.Control(func(fd uintptr) {
+		setErr = syscall.SetNonblock(int(fd), true)
+	}); ctlErr != nil {
+		return nil, ctlErr
+	}
+	if setErr != nil {
+		return nil, setErr
+	}
+
+	return &pollFile{f: f, rc: rc}, nil
+}
+
+func (p *pollFile) ioctl(req, arg uintptr) error {
+	var errno syscall.Errno
+	if err := p.rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (p *pollFile) fd() (int, error) {
+	var fd int
+	err := p.rc.Control(func(f uintptr) { fd = int(f) })
+	return fd, err
+}
+
+func (p *pollFile) write(ctx context.Context, data []byte) error {
+	fd, err := p.fd()
+	if err != nil {
+		return err
+	}
+
+	for len(data) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := syscall.Write(fd, data)
+		if err != nil {
+			if err == syscall.EAGAIN {
+				if err := waitFD(ctx, fd, pollOut); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (p *pollFile) read(ctx context.Context, size int) ([]byte, error) {
+	fd, err := p.fd()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			if err == syscall.EAGAIN {
+				if err := waitFD(ctx, fd, pollIn); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+func (p *pollFile) Close() error {
+	return p.f.Close()
+}
+
+// waitFD blocks until fd is ready for events, or until ctx's deadline passes.
+// It uses a single poll(2) call per attempt, bounded by that deadline, rather
+// than a fixed sleep. Cancelling a ctx that has no deadline does not wake the
+// poll; callers see the cancellation on their next ctx.Err() check.
+func waitFD(ctx context.Context, fd int, events int16) error {
+	timeoutMs := -1
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return ctx.Err()
+		}
+		timeoutMs = int(remaining.Milliseconds())
+		if timeoutMs == 0 {
+			timeoutMs = 1
+		}
+	}
+
+	pfd := pollFd{Fd: int32(fd), Events: events}
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_POLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(timeoutMs))
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return errno
+		}
+		break
+	}
+	if pfd.Revents&events == 0 {
+		return fmt.Errorf("i2c: timed out waiting for fd %d", fd)
+	}
+	return nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Bus owns an open, non-blocking handle to an /dev/i2c-* device for its
+// whole lifetime, rather than the open-ioctl-write-read-close dance
+// repeated on every single VCP access. Concurrent callers are serialised
+// with a mutex, since only one DDC/CI transaction can be in flight on a
+// bus at a time.
+type Bus struct {
+	path string
+	pf   *pollFile
+	mu   sync.Mutex
+}
+
+func OpenBus(path string) (*Bus, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := newPollFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Bus{path: path, pf: pf}, nil
+}
+
+func (b *Bus) setSlave(addr byte) error {
+	return b.pf.ioctl(I2C_SLAVE, uintptr(addr))
+}
+
+func (b *Bus) SetVCP(vcp byte, value uint16) error {
+	return b.SetVCPCtx(context.Background(), vcp, value)
+}
+
+func (b *Bus) GetVCP(vcp byte) (uint16, error) {
+	return b.GetVCPCtx(context.Background(), vcp)
+}
+
+func (b *Bus) SetVCPCtx(ctx context.Context, vcp byte, value uint16) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setSlave(DDC_ADDR); err != nil {
+		return err
+	}
+
+	data := []byte{0x51, 0x84, 0x03, vcp, byte(value >> 8), byte(value & 0xFF)}
+	data = append(data, getChecksum(data))
+
+	logVerbose("Writing to %s VCP 0x%02x value 0x%04x: %02x", b.path, vcp, value, data)
+
+	var lastErr error
+	for retry := 0; retry < 3; retry++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lastErr = b.pf.write(ctx, append([]byte(nil), data...))
+		if lastErr == nil {
+			return nil
+		}
+		logVerbose("Retrying write to %s (attempt %d): %v", b.path, retry+1, lastErr)
+		if err := sleepCtx(ctx, 200*time.Millisecond); err != nil {
+			return err
+		}
+	}
+	return lastErr
+}
+
+func (b *Bus) GetVCPCtx(ctx context.Context, vcp byte) (uint16, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setSlave(DDC_ADDR); err != nil {
+		return 0, err
+	}
+
+	req := []byte{0x51, 0x82, 0x01, vcp}
+	req = append(req, getChecksum(req))
+	if err := b.pf.write(ctx, req); err != nil {
+		return 0, err
+	}
+
+	for retry := 0; retry < 2; retry++ {
+		if err := sleepCtx(ctx, 150*time.Millisecond); err != nil {
+			return 0, err
+		}
+		reply, err := b.pf.read(ctx, 16)
+		if err == nil && len(reply) >= 10 {
+			for i := 0; i < len(reply)-5; i++ {
+				if reply[i] == 0x02 && reply[i+2] == vcp {
+					return uint16(reply[i+6])<<8 | uint16(reply[i+7]), nil
+				}
+			}
+			if reply[0] == 0x6e {
+				return uint16(reply[8])<<8 | uint16(reply[9]), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("failed read")
+}
+
+// Capabilities issues the DDC/CI Capabilities Request (opcode 0xF3) and
+// reassembles the (possibly multi-fragment) reply into the full
+// parenthesised capability string, sharing the same persistent connection
+// and locking as SetVCPCtx/GetVCPCtx. The reassembly is bounded by
+// maxCapabilitiesFragments/maxCapabilitiesBytes so a monitor whose firmware
+// never converges can't hang the caller forever.
+func (b *Bus) Capabilities() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setSlave(DDC_ADDR); err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	var caps bytes.Buffer
+	offset := uint16(0)
+	for fragment := 0; fragment < maxCapabilitiesFragments && caps.Len() < maxCapabilitiesBytes; fragment++ {
+		req := []byte{0x51, 0x83, VCP_CAPABILITIES, byte(offset >> 8), byte(offset & 0xFF)}
+		req = append(req, getChecksum(req))
+
+		if err := b.pf.write(ctx, req); err != nil {
+			return "", err
+		}
+		if err := sleepCtx(ctx, 50*time.Millisecond); err != nil {
+			return "", err
+		}
+
+		reply, err := b.pf.read(ctx, 40)
+		if err != nil || len(reply) < 5 || reply[2] != CAPABILITIES_REPLY {
+			break
+		}
+
+		dataLen := int(reply[1]&^0x80) - 3
+		if dataLen <= 0 || 5+dataLen > len(reply) {
+			break
+		}
+
+		caps.Write(reply[5 : 5+dataLen])
+		offset += uint16(dataLen)
+	}
+
+	return caps.String(), nil
+}
+
+func (b *Bus) ReadEDID() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.setSlave(EDID_ADDR); err != nil {
+		return nil, err
+	}
+	return b.pf.read(context.Background(), 128)
+}
+
+func (b *Bus) Close() error {
+	return b.pf.Close()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newPollFilePair returns two ends of a connected, non-blocking socketpair
+// wrapped as pollFiles, standing in for the two ends of a real DDC/CI
+// connection. Bus.SetVCPCtx/GetVCPCtx/Capabilities also issue an I2C_SLAVE
+// ioctl before touching the wire, which a socketpair fd doesn't support, so
+// those are exercised through the real i2c-dev-shaped device in production;
+// here we test the poll-and-retry plumbing (pollFile, waitFD, sleepCtx)
+// those methods are built on, since that's what's actually
+// context/cancellation-sensitive.
+func newPollFilePair(t *testing.T) (*pollFile, *pollFile) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("socketpair: %v", err)
+	}
+	a, err := newPollFile(os.NewFile(uintptr(fds[0]), "a"))
+	if err != nil {
+		t.Fatalf("newPollFile a: %v", err)
+	}
+	b, err := newPollFile(os.NewFile(uintptr(fds[1]), "b"))
+	if err != nil {
+		t.Fatalf("newPollFile b: %v", err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+func TestPollFileWriteReadRoundtrip(t *testing.T) {
+	a, b := newPollFilePair(t)
+
+	want := []byte{0x6e, 0x51, 0x84, 0x03, 0x10, 0x00, 0x64}
+	if err := a.write(context.Background(), want); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got, err := b.read(context.Background(), len(want))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("read %x; want %x", got, want)
+	}
+}
+
+func TestPollFileReadHonoursCancelledContext(t *testing.T) {
+	a, _ := newPollFilePair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := a.read(ctx, 16); err != context.Canceled {
+		t.Errorf("read with cancelled ctx = %v; want context.Canceled", err)
+	}
+}
+
+func TestWaitFDTimesOutWithoutData(t *testing.T) {
+	a, _ := newPollFilePair(t)
+	fd, err := a.fd()
+	if err != nil {
+		t.Fatalf("fd: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = waitFD(ctx, fd, pollIn)
+	if err == nil {
+		t.Fatal("waitFD on an idle fd returned nil; want a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waitFD took %v to give up on a 20ms deadline", elapsed)
+	}
+}
+
+func TestWaitFDReturnsWhenDataArrives(t *testing.T) {
+	a, b := newPollFilePair(t)
+	fd, err := a.fd()
+	if err != nil {
+		t.Fatalf("fd: %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.write(context.Background(), []byte{0x01})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := waitFD(ctx, fd, pollIn); err != nil {
+		t.Errorf("waitFD: %v", err)
+	}
+}
+
+func TestSleepCtxCancelledReturnsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := sleepCtx(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("sleepCtx with cancelled ctx = %v; want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepCtx with cancelled ctx took %v; want near-immediate return", elapsed)
+	}
+}
+
+func TestSleepCtxCompletesAfterDuration(t *testing.T) {
+	start := time.Now()
+	if err := sleepCtx(context.Background(), 10*time.Millisecond); err != nil {
+		t.Errorf("sleepCtx: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("sleepCtx returned after %v; want >= 10ms", elapsed)
+	}
+}
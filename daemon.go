@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	dbusServiceName   = "org.spdg.DellMonitor"
+	dbusObjectPath    = "/org/spdg/DellMonitor"
+	dbusInterfaceName = "org.spdg.DellMonitor"
+)
+
+// daemonState holds the long-lived discovery result a daemon keeps around
+// so callers don't pay the /dev/i2c-* open + EDID re-read cost on every
+// single D-Bus call, only re-running discovery on Rescan or a config edit.
+type daemonState struct {
+	mu         sync.RWMutex
+	configPath string
+	configs    []MonitorConfig
+	devices    []Device
+}
+
+func newDaemonState(configPath string) (*daemonState, error) {
+	ds := &daemonState{configPath: configPath}
+	if err := ds.rescan(); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func (ds *daemonState) rescan() error {
+	var configs []MonitorConfig
+	if ds.configPath != "" {
+		c, err := loadConfigs(ds.configPath)
+		if err != nil {
+			return err
+		}
+		configs = c
+	}
+	devices := discoverDevices(configs)
+
+	ds.mu.Lock()
+	old := ds.devices
+	ds.configs = configs
+	ds.devices = devices
+	ds.mu.Unlock()
+
+	for _, d := range old {
+		if err := d.Transport.Close(); err != nil {
+			logVerbose("closing old transport for %s: %v", d.Bus, err)
+		}
+	}
+	return nil
+}
+
+func (ds *daemonState) findDevice(bus string) *Device {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	for i := range ds.devices {
+		if ds.devices[i].Bus == bus {
+			return &ds.devices[i]
+		}
+	}
+	return nil
+}
+
+func (ds *daemonState) matchedDevices() []*Device {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	return matchedDevices(ds.devices)
+}
+
+func (ds *daemonState) listMonitors() []string {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+	entries := make([]string, 0, len(ds.devices))
+	for _, d := range ds.devices {
+		model := ""
+		if d.Config != nil {
+			model = d.Config.Model
+		}
+		entries = append(entries, fmt.Sprintf("%s|%s|%s", d.Bus, d.Name, model))
+	}
+	return entries
+}
+
+// watchConfigFile blocks watching configPath's directory for the file being
+// (re)written, and calls ds.rescan() each time it is, so edits to
+// monitors.json take effect without restarting the daemon. It returns only
+// if the watch itself can't be set up or the inotify fd errors out.
+func watchConfigFile(ds *daemonState, configPath string) error {
+	if configPath == "" {
+		return fmt.Errorf("daemon: no config file to watch")
+	}
+	dir := filepath.Dir(configPath)
+	base := filepath.Base(configPath)
+
+	fd, err := syscall.InotifyInit1(0)
+	if err != nil {
+		return fmt.Errorf("daemon: inotify init: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	const mask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		return fmt.Errorf("daemon: watch %s: %w", dir, err)
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			return fmt.Errorf("daemon: inotify read: %w", err)
+		}
+
+		reload := false
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameLen := int(ev.Len)
+			if nameLen > 0 {
+				name := strings.TrimRight(string(buf[off+syscall.SizeofInotifyEvent:off+syscall.SizeofInotifyEvent+nameLen]), "\x00")
+				if name == base {
+					reload = true
+				}
+			}
+			off += syscall.SizeofInotifyEvent + nameLen
+		}
+
+		if reload {
+			logVerbose("%s changed, reloading", configPath)
+			if err := ds.rescan(); err != nil {
+				logVerbose("reload of %s failed: %v", configPath, err)
+			}
+		}
+	}
+}
+
+// daemonMethods builds the org.spdg.DellMonitor method table against ds:
+// ListMonitors, GetFeature, SetFeature, ApplyPreset and Rescan, the surface
+// a thin CLI client and desktop hotkey daemons both talk to.
+func daemonMethods(ds *daemonState) map[string]dbusMethod {
+	return map[string]dbusMethod{
+		"ListMonitors": {
+			outSig: "as",
+			handler: func(args []string) ([]string, error) {
+				return []string{strings.Join(ds.listMonitors(), "\x1f")}, nil
+			},
+		},
+		"GetFeature": {
+			inSig:  "ss",
+			outSig: "s",
+			handler: func(args []string) ([]string, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("GetFeature expects (bus, feature)")
+				}
+				d := ds.findDevice(args[0])
+				if d == nil {
+					return nil, fmt.Errorf("unknown bus %s", args[0])
+				}
+				if d.Config == nil {
+					return nil, fmt.Errorf("no config matched for %s", args[0])
+				}
+				feat, ok := d.Config.Features[args[1]]
+				if !ok {
+					return nil, fmt.Errorf("feature %s not defined", args[1])
+				}
+				vcp, err := parseHex(feat.VCP)
+				if err != nil {
+					return nil, err
+				}
+				val, err := d.Transport.GetVCP(vcp)
+				if err != nil {
+					return nil, err
+				}
+				return []string{fmt.Sprintf("0x%04X", val)}, nil
+			},
+		},
+		"SetFeature": {
+			inSig:  "sss",
+			outSig: "",
+			handler: func(args []string) ([]string, error) {
+				if len(args) != 3 {
+					return nil, fmt.Errorf("SetFeature expects (bus, feature, value)")
+				}
+				d := ds.findDevice(args[0])
+				if d == nil {
+					return nil, fmt.Errorf("unknown bus %s", args[0])
+				}
+				return nil, applyFeature(d, args[1], args[2])
+			},
+		},
+		"ApplyPreset": {
+			inSig:  "s",
+			outSig: "",
+			handler: func(args []string) ([]string, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("ApplyPreset expects (name)")
+				}
+				name := args[0]
+				targets := ds.matchedDevices()
+
+				ctx := context.Background()
+				var wg sync.WaitGroup
+				var mu sync.Mutex
+				var firstErr error
+				for _, t := range targets {
+					preset, ok := t.Config.Presets[name]
+					if !ok {
+						continue
+					}
+					wg.Add(1)
+					go func(t *Device, preset map[string]string) {
+						defer wg.Done()
+						if err := applyPreset(ctx, t, name, preset); err != nil {
+							mu.Lock()
+							if firstErr == nil {
+								firstErr = err
+							}
+							mu.Unlock()
+						}
+					}(t, preset)
+				}
+				wg.Wait()
+				return nil, firstErr
+			},
+		},
+		"Rescan": {
+			inSig:  "",
+			outSig: "",
+			handler: func(args []string) ([]string, error) {
+				return nil, ds.rescan()
+			},
+		},
+	}
+}
+
+// runDaemon keeps discovered devices open for the life of the process,
+// serving them over D-Bus instead of the CLI's normal one-shot discovery.
+func runDaemon(configPath string) error {
+	ds, err := newDaemonState(configPath)
+	if err != nil {
+		return fmt.Errorf("daemon: initial scan: %w", err)
+	}
+
+	if configPath != "" {
+		go func() {
+			if err := watchConfigFile(ds, configPath); err != nil {
+				logVerbose("config watch stopped: %v", err)
+			}
+		}()
+	}
+
+	conn, err := dialSessionBus()
+	if err != nil {
+		return fmt.Errorf("daemon: connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.hello(); err != nil {
+		return fmt.Errorf("daemon: hello: %w", err)
+	}
+	if err := conn.requestName(dbusServiceName); err != nil {
+		return fmt.Errorf("daemon: request name %s: %w", dbusServiceName, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "dell-monitor-tool daemon listening as %s\n", dbusServiceName)
+	return conn.serve(dbusObjectPath, dbusInterfaceName, daemonMethods(ds))
+}
+
+// dialDaemon is the CLI thin-client's half: it tries to reach an
+// already-running daemon, returning ok=false (never an error) if one isn't
+// reachable so callers fall back to the direct /dev/i2c-* path.
+func dialDaemon() (conn *dbusConn, ok bool) {
+	c, err := dialSessionBus()
+	if err != nil {
+		return nil, false
+	}
+	if _, err := c.hello(); err != nil {
+		c.Close()
+		return nil, false
+	}
+	if _, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "GetNameOwner", "s", []string{dbusServiceName}); err != nil {
+		c.Close()
+		return nil, false
+	}
+	return c, true
+}
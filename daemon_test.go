@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeDaemonTransport is a minimal Transport for exercising daemonState and
+// daemonMethods without real hardware: GetVCP/SetVCP return canned
+// values/errors, and Close just records that it happened.
+type fakeDaemonTransport struct {
+	vcpValue uint16
+	vcpErr   error
+	setErr   error
+	closed   bool
+}
+
+func (f *fakeDaemonTransport) GetVCP(vcp byte) (uint16, error)     { return f.vcpValue, f.vcpErr }
+func (f *fakeDaemonTransport) SetVCP(vcp byte, value uint16) error { return f.setErr }
+func (f *fakeDaemonTransport) ReadEDID() ([]byte, error)           { return nil, nil }
+func (f *fakeDaemonTransport) Close() error                        { f.closed = true; return nil }
+
+func testDevice(bus, name string, cfg *MonitorConfig, transport Transport) Device {
+	return Device{Bus: bus, Name: name, Config: cfg, Transport: transport}
+}
+
+func TestDaemonStateFindDevice(t *testing.T) {
+	ds := &daemonState{devices: []Device{
+		testDevice("/dev/i2c-1", "Left", nil, &fakeDaemonTransport{}),
+		testDevice("/dev/i2c-2", "Right", nil, &fakeDaemonTransport{}),
+	}}
+
+	if d := ds.findDevice("/dev/i2c-2"); d == nil || d.Name != "Right" {
+		t.Errorf("findDevice(/dev/i2c-2) = %v; want Right", d)
+	}
+	if d := ds.findDevice("/dev/i2c-9"); d != nil {
+		t.Errorf("findDevice(/dev/i2c-9) = %v; want nil", d)
+	}
+}
+
+func TestDaemonStateListMonitors(t *testing.T) {
+	ds := &daemonState{devices: []Device{
+		testDevice("/dev/i2c-1", "Left", &MonitorConfig{Model: "Dell U4021QW"}, &fakeDaemonTransport{}),
+		testDevice("/dev/i2c-2", "Right", nil, &fakeDaemonTransport{}),
+	}}
+
+	got := ds.listMonitors()
+	want := []string{"/dev/i2c-1|Left|Dell U4021QW", "/dev/i2c-2|Right|"}
+	if len(got) != len(want) {
+		t.Fatalf("listMonitors() = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDaemonStateRescanClosesOldTransports(t *testing.T) {
+	old := &fakeDaemonTransport{}
+	ds := &daemonState{devices: []Device{testDevice("/dev/i2c-1", "Left", nil, old)}}
+
+	// No configPath, so rescan skips loadConfigs and just re-runs discovery,
+	// which finds nothing in this sandbox (no /dev/i2c-* or /dev/hidraw*) -
+	// letting us confirm it closes out the previous generation either way.
+	if err := ds.rescan(); err != nil {
+		t.Fatalf("rescan: %v", err)
+	}
+	if !old.closed {
+		t.Error("rescan did not close the previous generation's transport")
+	}
+	if len(ds.devices) != 0 {
+		t.Errorf("devices after rescan = %v; want none in this sandbox", ds.devices)
+	}
+}
+
+func TestDaemonMethodsGetFeature(t *testing.T) {
+	cfg := &MonitorConfig{Features: map[string]FeatureConfig{
+		"input_source": {VCP: "0x60", Values: map[string]string{"hdmi1": "0x11"}},
+	}}
+	ds := &daemonState{devices: []Device{
+		testDevice("/dev/i2c-1", "Left", cfg, &fakeDaemonTransport{vcpValue: 0x11}),
+	}}
+	methods := daemonMethods(ds)
+
+	got, err := methods["GetFeature"].handler([]string{"/dev/i2c-1", "input_source"})
+	if err != nil {
+		t.Fatalf("GetFeature: %v", err)
+	}
+	if len(got) != 1 || got[0] != "0x0011" {
+		t.Errorf("GetFeature = %v; want [0x0011]", got)
+	}
+
+	if _, err := methods["GetFeature"].handler([]string{"/dev/i2c-9", "input_source"}); err == nil {
+		t.Error("GetFeature on unknown bus did not error")
+	}
+	if _, err := methods["GetFeature"].handler([]string{"/dev/i2c-1", "brightness"}); err == nil {
+		t.Error("GetFeature on an undefined feature did not error")
+	}
+}
+
+func TestDaemonMethodsSetFeature(t *testing.T) {
+	cfg := &MonitorConfig{Features: map[string]FeatureConfig{
+		"input_source": {VCP: "0x60", Values: map[string]string{"hdmi1": "0x11"}},
+	}}
+	transport := &fakeDaemonTransport{}
+	ds := &daemonState{devices: []Device{testDevice("/dev/i2c-1", "Left", cfg, transport)}}
+	methods := daemonMethods(ds)
+
+	if _, err := methods["SetFeature"].handler([]string{"/dev/i2c-1", "input_source", "hdmi1"}); err != nil {
+		t.Fatalf("SetFeature: %v", err)
+	}
+
+	if _, err := methods["SetFeature"].handler([]string{"/dev/i2c-9", "input_source", "hdmi1"}); err == nil {
+		t.Error("SetFeature on unknown bus did not error")
+	}
+}
+
+func TestDaemonMethodsListMonitors(t *testing.T) {
+	ds := &daemonState{devices: []Device{
+		testDevice("/dev/i2c-1", "Left", &MonitorConfig{Model: "Dell U4021QW"}, &fakeDaemonTransport{}),
+	}}
+	methods := daemonMethods(ds)
+
+	got, err := methods["ListMonitors"].handler(nil)
+	if err != nil {
+		t.Fatalf("ListMonitors: %v", err)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "/dev/i2c-1|Left|Dell U4021QW") {
+		t.Errorf("ListMonitors = %v", got)
+	}
+}
+
+func TestDaemonMethodsRescan(t *testing.T) {
+	ds := &daemonState{devices: []Device{testDevice("/dev/i2c-1", "Left", nil, &fakeDaemonTransport{})}}
+	methods := daemonMethods(ds)
+
+	if _, err := methods["Rescan"].handler(nil); err != nil {
+		t.Fatalf("Rescan: %v", err)
+	}
+}
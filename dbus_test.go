@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalUnmarshalString(t *testing.T) {
+	buf := marshalString([]byte{0xAA}, "hello")
+	s, off, err := unmarshalString(buf, 1)
+	if err != nil {
+		t.Fatalf("unmarshalString: %v", err)
+	}
+	if s != "hello" {
+		t.Errorf("got %q; want %q", s, "hello")
+	}
+	if off != len(buf) {
+		t.Errorf("offset = %d; want %d", off, len(buf))
+	}
+}
+
+func TestMarshalUnmarshalStringArray(t *testing.T) {
+	want := []string{"a", "bus0", ""}
+	buf := marshalStringArray(nil, want)
+	got, off, err := unmarshalStringArray(buf, 0)
+	if err != nil {
+		t.Fatalf("unmarshalStringArray: %v", err)
+	}
+	if off != len(buf) {
+		t.Errorf("offset = %d; want %d", off, len(buf))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("element %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEncodeReadMessageMethodCall(t *testing.T) {
+	body, err := marshalBody("ss", []string{"/dev/i2c-3", "input_source"})
+	if err != nil {
+		t.Fatalf("marshalBody: %v", err)
+	}
+	fields := []dbusHeaderField{
+		{code: dbusFieldPath, sig: "o", str: dbusObjectPath},
+		{code: dbusFieldInterface, sig: "s", str: dbusInterfaceName},
+		{code: dbusFieldMember, sig: "s", str: "GetFeature"},
+		{code: dbusFieldDestination, sig: "s", str: dbusServiceName},
+	}
+	raw := encodeMessage(dbusMsgMethodCall, 7, fields, "ss", body)
+
+	msg, err := readMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("readMessage: %v", err)
+	}
+	if msg.msgType != dbusMsgMethodCall {
+		t.Errorf("msgType = %d; want %d", msg.msgType, dbusMsgMethodCall)
+	}
+	if msg.serial != 7 {
+		t.Errorf("serial = %d; want 7", msg.serial)
+	}
+	if msg.path != dbusObjectPath || msg.iface != dbusInterfaceName || msg.member != "GetFeature" || msg.destination != dbusServiceName {
+		t.Errorf("unexpected header fields: %+v", msg)
+	}
+	want := []string{"/dev/i2c-3", "input_source"}
+	if len(msg.args) != len(want) || msg.args[0] != want[0] || msg.args[1] != want[1] {
+		t.Errorf("args = %v; want %v", msg.args, want)
+	}
+}
+
+func TestEncodeReadMessageArrayReturn(t *testing.T) {
+	body := marshalArrayBody([]string{"/dev/i2c-3|Dell U4021QW|U4021QW"})
+	fields := []dbusHeaderField{
+		{code: dbusFieldReplySerial, sig: "u", u32: 3},
+	}
+	raw := encodeMessage(dbusMsgMethodReturn, 8, fields, "as", body)
+
+	msg, err := readMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("readMessage: %v", err)
+	}
+	if msg.replySerial != 3 {
+		t.Errorf("replySerial = %d; want 3", msg.replySerial)
+	}
+	if len(msg.args) != 1 || msg.args[0] != "/dev/i2c-3|Dell U4021QW|U4021QW" {
+		t.Errorf("args = %v", msg.args)
+	}
+}
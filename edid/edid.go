@@ -0,0 +1,199 @@
+// Package edid parses VESA E-EDID 1.4 data as read over DDC/CI from a
+// monitor's EEPROM: the 128-byte base block plus any CEA-861 extension
+// blocks that follow it.
+package edid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	baseBlockSize = 128
+	descriptorLen = 18
+
+	descriptorName        = 0xFC
+	descriptorSerial      = 0xFF
+	descriptorRangeLimits = 0xFD
+	descriptorAdditional  = 0xFA
+)
+
+var headerMagic = [8]byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+
+// RangeLimits is the monitor range limits descriptor (type 0xFD): the
+// vertical/horizontal frequency ranges and maximum pixel clock it accepts.
+type RangeLimits struct {
+	MinVertHz        int
+	MaxVertHz        int
+	MinHorzKHz       int
+	MaxHorzKHz       int
+	MaxPixelClockMHz int
+}
+
+// DetailedTiming is one 18-byte detailed timing descriptor: the first one
+// in the base block is the display's preferred timing.
+type DetailedTiming struct {
+	PixelClockKHz int
+	HActive       int
+	HBlank        int
+	VActive       int
+	VBlank        int
+	HSyncOffset   int
+	HSyncWidth    int
+	VSyncOffset   int
+	VSyncWidth    int
+	HImageSizeMM  int
+	VImageSizeMM  int
+	HBorder       int
+	VBorder       int
+	Interlaced    bool
+}
+
+// EDID is the parsed result of a monitor's base EDID block and any
+// extension blocks that followed it.
+type EDID struct {
+	ManufacturerID    string
+	ProductCode       uint16
+	SerialNumber      uint32
+	WeekOfManufacture byte
+	YearOfManufacture int
+
+	Name              string
+	SerialString      string
+	RangeLimits       *RangeLimits
+	AdditionalTimings []byte
+
+	PreferredTiming *DetailedTiming
+	DetailedTimings []DetailedTiming
+
+	Extensions [][]byte
+}
+
+// Parse decodes a 128-byte (or longer, with extension blocks appended)
+// EDID read, verifying the base block checksum before trusting it.
+func Parse(data []byte) (*EDID, error) {
+	if len(data) < baseBlockSize {
+		return nil, fmt.Errorf("edid: data too short: got %d bytes, want at least %d", len(data), baseBlockSize)
+	}
+	base := data[:baseBlockSize]
+
+	var magic [8]byte
+	copy(magic[:], base[0:8])
+	if magic != headerMagic {
+		return nil, fmt.Errorf("edid: missing EDID header magic")
+	}
+	if checksum(base) != 0 {
+		return nil, fmt.Errorf("edid: base block checksum failed")
+	}
+
+	e := &EDID{
+		ManufacturerID:    manufacturerID(base),
+		ProductCode:       binary.LittleEndian.Uint16(base[10:12]),
+		SerialNumber:      binary.LittleEndian.Uint32(base[12:16]),
+		WeekOfManufacture: base[16],
+		YearOfManufacture: int(base[17]) + 1990,
+	}
+
+	for off := 54; off <= 108; off += descriptorLen {
+		desc := base[off : off+descriptorLen]
+		if isTextDescriptor(desc) {
+			switch desc[3] {
+			case descriptorName:
+				e.Name = descriptorText(desc)
+			case descriptorSerial:
+				e.SerialString = descriptorText(desc)
+			case descriptorRangeLimits:
+				e.RangeLimits = parseRangeLimits(desc)
+			case descriptorAdditional:
+				e.AdditionalTimings = append([]byte(nil), desc[5:18]...)
+			}
+			continue
+		}
+
+		timing := parseDetailedTiming(desc)
+		if off == 54 {
+			preferred := timing
+			e.PreferredTiming = &preferred
+		}
+		e.DetailedTimings = append(e.DetailedTimings, timing)
+	}
+
+	numExt := int(base[126])
+	for i := 0; i < numExt; i++ {
+		start := baseBlockSize + i*baseBlockSize
+		end := start + baseBlockSize
+		if end > len(data) {
+			break
+		}
+		e.Extensions = append(e.Extensions, append([]byte(nil), data[start:end]...))
+	}
+
+	return e, nil
+}
+
+func checksum(block []byte) byte {
+	var sum byte
+	for _, b := range block {
+		sum += b
+	}
+	return sum
+}
+
+// manufacturerID decodes the 3-letter PNP manufacturer ID packed into bits
+// 0-14 of bytes 8-9 (big-endian, 5 bits per letter, 'A' = 1).
+func manufacturerID(base []byte) string {
+	v := uint16(base[8])<<8 | uint16(base[9])
+	letters := [3]byte{
+		byte((v>>10)&0x1F) + 'A' - 1,
+		byte((v>>5)&0x1F) + 'A' - 1,
+		byte(v&0x1F) + 'A' - 1,
+	}
+	return string(letters[:])
+}
+
+// isTextDescriptor reports whether desc is a display descriptor (flagged
+// by a zero pixel clock) rather than a detailed timing descriptor.
+func isTextDescriptor(desc []byte) bool {
+	return desc[0] == 0 && desc[1] == 0 && desc[2] == 0
+}
+
+func descriptorText(desc []byte) string {
+	s := string(desc[5:18])
+	if idx := strings.IndexByte(s, '\n'); idx != -1 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(s)
+}
+
+func parseRangeLimits(desc []byte) *RangeLimits {
+	return &RangeLimits{
+		MinVertHz:        int(desc[5]),
+		MaxVertHz:        int(desc[6]),
+		MinHorzKHz:       int(desc[7]),
+		MaxHorzKHz:       int(desc[8]),
+		MaxPixelClockMHz: int(desc[9]) * 10,
+	}
+}
+
+// parseDetailedTiming decodes an 18-byte detailed timing descriptor per
+// the VESA EDID bit layout (pixel clock in byte 0-1, active/blanking split
+// across the low bytes and the shared high nibble in byte 4/7/11/14).
+func parseDetailedTiming(desc []byte) DetailedTiming {
+	return DetailedTiming{
+		PixelClockKHz: (int(desc[0]) | int(desc[1])<<8) * 10,
+		HActive:       int(desc[2]) | int(desc[4]&0xF0)<<4,
+		HBlank:        int(desc[3]) | int(desc[4]&0x0F)<<8,
+		VActive:       int(desc[5]) | int(desc[7]&0xF0)<<4,
+		VBlank:        int(desc[6]) | int(desc[7]&0x0F)<<8,
+		HSyncOffset:   int(desc[8]) | int(desc[11]&0xC0)<<2,
+		HSyncWidth:    int(desc[9]) | int(desc[11]&0x30)<<4,
+		VSyncOffset:   int(desc[10]>>4) | int(desc[11]&0x0C)<<2,
+		VSyncWidth:    int(desc[10]&0x0F) | int(desc[11]&0x03)<<4,
+		HImageSizeMM:  int(desc[12]) | int(desc[14]&0xF0)<<4,
+		VImageSizeMM:  int(desc[13]) | int(desc[14]&0x0F)<<8,
+		HBorder:       int(desc[15]),
+		VBorder:       int(desc[16]),
+		Interlaced:    desc[17]&0x80 != 0,
+	}
+}
@@ -0,0 +1,101 @@
+package edid
+
+import "testing"
+
+// buildBaseBlock returns a valid 128-byte EDID base block for "DEL" /
+// product 0x9999, with the given descriptors placed starting at byte 54.
+func buildBaseBlock(t *testing.T, descriptors ...[]byte) []byte {
+	t.Helper()
+	b := make([]byte, 128)
+	copy(b[0:8], headerMagic[:])
+	b[8], b[9] = 0x10, 0xAC // "DEL"
+	b[10], b[11] = 0x99, 0x99
+	b[12], b[13], b[14], b[15] = 0x01, 0x00, 0x00, 0x00
+	b[16] = 10 // week
+	b[17] = 30 // year offset -> 2020
+
+	off := 54
+	for _, d := range descriptors {
+		if len(d) != descriptorLen {
+			t.Fatalf("descriptor must be %d bytes, got %d", descriptorLen, len(d))
+		}
+		copy(b[off:off+descriptorLen], d)
+		off += descriptorLen
+	}
+
+	b[126] = 0 // no extension blocks
+	var sum byte
+	for _, v := range b[:127] {
+		sum += v
+	}
+	b[127] = byte(256 - int(sum))
+	return b
+}
+
+func nameDescriptor(name string) []byte {
+	d := make([]byte, descriptorLen)
+	d[3] = descriptorName
+	copy(d[5:18], []byte(name+"\n"))
+	for i := 5 + len(name) + 1; i < 18; i++ {
+		d[i] = ' '
+	}
+	return d
+}
+
+func TestParseRejectsShortData(t *testing.T) {
+	if _, err := Parse(make([]byte, 64)); err == nil {
+		t.Error("expected an error for data shorter than a base block")
+	}
+}
+
+func TestParseRejectsBadChecksum(t *testing.T) {
+	b := buildBaseBlock(t, nameDescriptor("Dell U4021QW"))
+	b[127] ^= 0xFF
+	if _, err := Parse(b); err == nil {
+		t.Error("expected an error for a corrupt checksum")
+	}
+}
+
+func TestParseManufacturerAndProduct(t *testing.T) {
+	b := buildBaseBlock(t, nameDescriptor("Dell U4021QW"))
+	e, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if e.ManufacturerID != "DEL" {
+		t.Errorf("ManufacturerID = %q; want DEL", e.ManufacturerID)
+	}
+	if e.ProductCode != 0x9999 {
+		t.Errorf("ProductCode = 0x%04X; want 0x9999", e.ProductCode)
+	}
+	if e.YearOfManufacture != 2020 {
+		t.Errorf("YearOfManufacture = %d; want 2020", e.YearOfManufacture)
+	}
+	if e.Name != "Dell U4021QW" {
+		t.Errorf("Name = %q; want %q", e.Name, "Dell U4021QW")
+	}
+}
+
+func TestParseDetailedTiming(t *testing.T) {
+	timing := make([]byte, descriptorLen)
+	// 533.25 MHz pixel clock -> 53325 in 10kHz units, little-endian.
+	timing[0], timing[1] = byte(53325&0xFF), byte(53325>>8)
+	timing[2] = 0xA0 // HActive low 8 bits
+	timing[4] = 0x10 // HActive high nibble -> 0x1A0 = 416... just needs to round-trip
+
+	b := buildBaseBlock(t, timing, nameDescriptor("Dell U4021QW"))
+	e, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if e.PreferredTiming == nil {
+		t.Fatal("expected a preferred timing to be parsed")
+	}
+	if e.PreferredTiming.PixelClockKHz != 533250 {
+		t.Errorf("PixelClockKHz = %d; want 533250", e.PreferredTiming.PixelClockKHz)
+	}
+	wantHActive := int(timing[2]) | int(timing[4]&0xF0)<<4
+	if e.PreferredTiming.HActive != wantHActive {
+		t.Errorf("HActive = %d; want %d", e.PreferredTiming.HActive, wantHActive)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SPDG/dell-monitor-tool/edid"
+)
+
+const (
+	VCP_CAPABILITIES   = 0xF3
+	CAPABILITIES_REPLY = 0xE3
+
+	// maxCapabilitiesFragments and maxCapabilitiesBytes bound the
+	// reassembly loop in Bus.Capabilities, so a monitor whose firmware
+	// never converges (always answers with a positive-length fragment)
+	// can't hang --generate-config forever.
+	maxCapabilitiesFragments = 64
+	maxCapabilitiesBytes     = 16384
+)
+
+// knownVCPLabels maps well-known VCP opcodes to the human-readable feature
+// names used elsewhere in this tool (presets, --input, --pbp, ...).
+var knownVCPLabels = map[byte]string{
+	0x10: "brightness",
+	0x12: "contrast",
+	0x60: "input_source",
+	0x8D: "pbp_sub_input",
+	0xE9: "pbp_mode",
+	0xEA: "usb_selection",
+}
+
+// knownValueLabels maps well-known VCP opcodes to human labels for their
+// enumerated legal values, as reported in a monitor's capability string.
+var knownValueLabels = map[byte]map[byte]string{
+	0x60: {
+		0x0F: "dp1",
+		0x10: "dp2",
+		0x11: "hdmi1",
+		0x12: "hdmi2",
+		0x1B: "usbc",
+	},
+	0xE9: {
+		0x00: "off",
+		0x01: "pbp",
+		0x02: "pip",
+	},
+}
+
+// extractSection returns the contents of a balanced-parenthesis key(...)
+// section from a DDC/CI capability string, e.g. extractSection(s, "vcp").
+func extractSection(caps, key string) string {
+	idx := strings.Index(caps, key+"(")
+	if idx == -1 {
+		return ""
+	}
+	start := idx + len(key) + 1
+
+	depth := 1
+	i := start
+	for i < len(caps) && depth > 0 {
+		switch caps[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		i++
+	}
+	return caps[start : i-1]
+}
+
+type vcpCapability struct {
+	Code   byte
+	Values []byte
+}
+
+// parseVCPList tokenizes the body of a capability string's vcp(...) section
+// into one entry per VCP code, e.g. "60(0F 11 12 1B)" becomes the code 0x60
+// with legal values 0x0F, 0x11, 0x12, 0x1B.
+func parseVCPList(section string) []vcpCapability {
+	var result []vcpCapability
+	i := 0
+	for i < len(section) {
+		for i < len(section) && section[i] == ' ' {
+			i++
+		}
+		if i >= len(section) {
+			break
+		}
+
+		start := i
+		for i < len(section) && section[i] != ' ' && section[i] != '(' {
+			i++
+		}
+		codeStr := section[start:i]
+		if codeStr == "" {
+			break
+		}
+		code, err := parseHex(codeStr)
+		if err != nil {
+			break
+		}
+		entry := vcpCapability{Code: code}
+
+		if i < len(section) && section[i] == '(' {
+			depth := 1
+			j := i + 1
+			valStart := j
+			for j < len(section) && depth > 0 {
+				if section[j] == '(' {
+					depth++
+				} else if section[j] == ')' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+				j++
+			}
+			for _, v := range strings.Fields(section[valStart:j]) {
+				if val, err := parseHex(v); err == nil {
+					entry.Values = append(entry.Values, val)
+				}
+			}
+			i = j + 1
+		}
+
+		result = append(result, entry)
+	}
+	return result
+}
+
+// vcpFeatureName returns the human label used for a VCP opcode, falling
+// back to a generic vcp_XX name for codes this tool doesn't know about.
+func vcpFeatureName(code byte) string {
+	if name, ok := knownVCPLabels[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("vcp_%02x", code)
+}
+
+// vcpValueLabel returns the human label for one of a VCP opcode's legal
+// values, falling back to a generic value_XX label.
+func vcpValueLabel(code, value byte) string {
+	if labels, ok := knownValueLabels[code]; ok {
+		if label, ok := labels[value]; ok {
+			return label
+		}
+	}
+	return fmt.Sprintf("value_%02x", value)
+}
+
+// capabilitiesToFeatures turns a raw DDC/CI capability string into the
+// Features map of a MonitorConfig.
+func capabilitiesToFeatures(raw string) map[string]FeatureConfig {
+	features := make(map[string]FeatureConfig)
+	for _, entry := range parseVCPList(extractSection(raw, "vcp")) {
+		values := make(map[string]string)
+		for _, v := range entry.Values {
+			values[vcpValueLabel(entry.Code, v)] = fmt.Sprintf("0x%02X", v)
+		}
+		features[vcpFeatureName(entry.Code)] = FeatureConfig{
+			VCP:    fmt.Sprintf("0x%02X", entry.Code),
+			Values: values,
+		}
+	}
+	return features
+}
+
+// buildMonitorConfig discovers everything generate-config needs about a
+// single monitor reachable over bus: its EDID-derived model and match
+// criteria, and its DDC/CI-reported features. It goes through the same
+// persistent, serialised Bus connection as the rest of the tool rather than
+// opening the device file itself.
+func buildMonitorConfig(bus *Bus) (*MonitorConfig, error) {
+	raw, err := bus.ReadEDID()
+	if err != nil {
+		return nil, fmt.Errorf("reading EDID: %w", err)
+	}
+
+	e, err := edid.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing EDID: %w", err)
+	}
+
+	model := e.Name
+	if model == "" {
+		model = fmt.Sprintf("%s Monitor", e.ManufacturerID)
+	}
+
+	caps, err := bus.Capabilities()
+	if err != nil {
+		return nil, fmt.Errorf("reading capabilities: %w", err)
+	}
+
+	return &MonitorConfig{
+		Model:    model,
+		Match:    MatchCriteria{PNPID: e.ManufacturerID, ProductCode: e.ProductCode},
+		Features: capabilitiesToFeatures(caps),
+	}, nil
+}
+
+// runGenerateConfig scans every /dev/i2c-* bus with a valid EDID, builds a
+// MonitorConfig for each, and writes the result as JSON to outputPath (or
+// stdout if outputPath is empty).
+func runGenerateConfig(outputPath string) error {
+	matches, _ := filepath.Glob("/dev/i2c-*")
+
+	var configs []MonitorConfig
+	for _, path := range matches {
+		bus, err := OpenBus(path)
+		if err != nil {
+			logVerbose("Skipping %s: %v", path, err)
+			continue
+		}
+		cfg, err := buildMonitorConfig(bus)
+		bus.Close()
+		if err != nil {
+			logVerbose("Skipping %s: %v", path, err)
+			continue
+		}
+		configs = append(configs, *cfg)
+	}
+
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if outputPath == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+const sampleCapabilities = "(prot(monitor)type(lcd)model(U4021QW)cmds(01 02 03 0C E3 F3)vcp(02 04 05 08 10 12 14(05 06 08 0B) 16 18 1A 52 60(0F 11 12 1B) AC AE B2 B6 C0 C6 C8 C9 D6(01 04 05) DF 62 8D(3E 00) F0 F1 F2 FD E9(00 01 02) EA(00 01 02 03))mswhql(1)asset_eep(40)mccs_ver(2.2))"
+
+func TestExtractSection(t *testing.T) {
+	section := extractSection(sampleCapabilities, "vcp")
+	if section == "" {
+		t.Fatal("expected a non-empty vcp section")
+	}
+	if section[:2] != "02" {
+		t.Errorf("expected vcp section to start with 02, got %q", section[:2])
+	}
+
+	if got := extractSection(sampleCapabilities, "model"); got != "U4021QW" {
+		t.Errorf("extractSection(model) = %q; want U4021QW", got)
+	}
+
+	if got := extractSection(sampleCapabilities, "nope"); got != "" {
+		t.Errorf("extractSection(nope) = %q; want empty", got)
+	}
+}
+
+func TestParseVCPList(t *testing.T) {
+	entries := parseVCPList(extractSection(sampleCapabilities, "vcp"))
+
+	byCode := make(map[byte]vcpCapability)
+	for _, e := range entries {
+		byCode[e.Code] = e
+	}
+
+	if _, ok := byCode[0x02]; !ok {
+		t.Error("expected VCP 0x02 with no values to be present")
+	}
+	if len(byCode[0x02].Values) != 0 {
+		t.Errorf("VCP 0x02 should have no enumerated values, got %v", byCode[0x02].Values)
+	}
+
+	input, ok := byCode[0x60]
+	if !ok {
+		t.Fatal("expected VCP 0x60 (input source) to be present")
+	}
+	want := []byte{0x0F, 0x11, 0x12, 0x1B}
+	if len(input.Values) != len(want) {
+		t.Fatalf("VCP 0x60 values = %v; want %v", input.Values, want)
+	}
+	for i, v := range want {
+		if input.Values[i] != v {
+			t.Errorf("VCP 0x60 value[%d] = 0x%02X; want 0x%02X", i, input.Values[i], v)
+		}
+	}
+}
+
+func TestCapabilitiesToFeatures(t *testing.T) {
+	features := capabilitiesToFeatures(sampleCapabilities)
+
+	input, ok := features["input_source"]
+	if !ok {
+		t.Fatal("expected an input_source feature from VCP 0x60")
+	}
+	if input.VCP != "0x60" {
+		t.Errorf("input_source.VCP = %s; want 0x60", input.VCP)
+	}
+	if input.Values["hdmi1"] != "0x11" {
+		t.Errorf("input_source value hdmi1 = %s; want 0x11", input.Values["hdmi1"])
+	}
+
+	pbp, ok := features["pbp_mode"]
+	if !ok {
+		t.Fatal("expected a pbp_mode feature from VCP 0xE9")
+	}
+	if pbp.Values["pbp"] != "0x01" {
+		t.Errorf("pbp_mode value pbp = %s; want 0x01", pbp.Values["pbp"])
+	}
+
+	unknown, ok := features["vcp_d6"]
+	if !ok {
+		t.Fatal("expected a generic vcp_d6 feature for the unlabelled VCP 0xD6")
+	}
+	if unknown.Values["value_01"] != "0x01" {
+		t.Errorf("vcp_d6 value_01 = %s; want 0x01", unknown.Values["value_01"])
+	}
+}
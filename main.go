@@ -1,15 +1,17 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
+
+	"github.com/SPDG/dell-monitor-tool/edid"
 )
 
 const (
@@ -23,17 +25,54 @@ type FeatureConfig struct {
 	Values map[string]string `json:"values"`
 }
 
+type USBMatch struct {
+	VendorID  uint16 `json:"vendor_id"`
+	ProductID uint16 `json:"product_id"`
+}
+
+// MatchCriteria identifies a monitor by any combination of its EDID PNP
+// manufacturer ID, EDID product code, and a substring of its display name.
+// A zero-value field is not checked; every non-zero field set must match.
+type MatchCriteria struct {
+	PNPID        string `json:"pnp_id,omitempty"`
+	ProductCode  uint16 `json:"product_code,omitempty"`
+	NameContains string `json:"name_contains,omitempty"`
+}
+
+// Matches reports whether this criteria identifies the monitor described by
+// e (its parsed EDID, which may be nil if parsing failed) and name (its
+// best-effort display name). A criteria with every field empty never
+// matches, since that would match any monitor.
+func (m MatchCriteria) Matches(e *edid.EDID, name string) bool {
+	if m.PNPID == "" && m.ProductCode == 0 && m.NameContains == "" {
+		return false
+	}
+	if m.PNPID != "" && (e == nil || !strings.EqualFold(e.ManufacturerID, m.PNPID)) {
+		return false
+	}
+	if m.ProductCode != 0 && (e == nil || e.ProductCode != m.ProductCode) {
+		return false
+	}
+	if m.NameContains != "" && !strings.Contains(strings.ToUpper(name), strings.ToUpper(m.NameContains)) {
+		return false
+	}
+	return true
+}
+
 type MonitorConfig struct {
 	Model    string                       `json:"model"`
-	Match    string                       `json:"match"`
+	Match    MatchCriteria                `json:"match"`
+	USB      *USBMatch                    `json:"usb,omitempty"`
 	Features map[string]FeatureConfig     `json:"features"`
 	Presets  map[string]map[string]string `json:"presets"`
 }
 
 type Device struct {
-	Bus    string
-	Name   string
-	Config *MonitorConfig
+	Bus       string
+	Name      string
+	Config    *MonitorConfig
+	Transport Transport
+	EDID      *edid.EDID
 }
 
 var verbose bool
@@ -52,121 +91,52 @@ func getChecksum(data []byte) byte {
 	return checksum
 }
 
-func parseHex(s string) byte {
+func parseHex(s string) (byte, error) {
 	var b byte
-	fmt.Sscanf(s, "0x%x", &b)
-	if b == 0 {
-		fmt.Sscanf(s, "%x", &b)
+	if n, err := fmt.Sscanf(s, "0x%x", &b); err == nil && n == 1 {
+		return b, nil
 	}
-	return b
-}
-
-func parseHex16(s string) uint16 {
-	var v uint16
-	fmt.Sscanf(s, "0x%x", &v)
-	if v == 0 {
-		fmt.Sscanf(s, "%x", &v)
+	if n, err := fmt.Sscanf(s, "%x", &b); err == nil && n == 1 {
+		return b, nil
 	}
-	return v
+	return 0, fmt.Errorf("invalid hex byte %q", s)
 }
 
-func setVCP(bus string, vcp byte, value uint16) error {
-	f, err := os.OpenFile(bus, os.O_RDWR, 0)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2C_SLAVE, uintptr(DDC_ADDR)); errno != 0 {
-		return errno
+func parseHex16(s string) (uint16, error) {
+	var v uint16
+	if n, err := fmt.Sscanf(s, "0x%x", &v); err == nil && n == 1 {
+		return v, nil
 	}
-
-	data := []byte{0x51, 0x84, 0x03, vcp, byte(value >> 8), byte(value & 0xFF)}
-	data = append(data, getChecksum(data))
-
-	logVerbose("Writing to %s VCP 0x%02x value 0x%04x: %02x", bus, vcp, value, data)
-
-	var lastErr error
-	for retry := 0; retry < 3; retry++ {
-		_, lastErr = f.Write(data)
-		if lastErr == nil {
-			return nil
-		}
-		logVerbose("Retrying write to %s (attempt %d): %v", bus, retry+1, lastErr)
-		time.Sleep(200 * time.Millisecond)
+	if n, err := fmt.Sscanf(s, "%x", &v); err == nil && n == 1 {
+		return v, nil
 	}
-	return lastErr
+	return 0, fmt.Errorf("invalid hex value %q", s)
 }
 
-func getVCP(bus string, vcp byte) (uint16, error) {
-	f, err := os.OpenFile(bus, os.O_RDWR, 0)
+// identifyMonitor reads and parses the EDID off t, returning both the
+// structured result and a best-effort display name (the EDID's 0xFC name
+// descriptor, falling back to "<manufacturer> Monitor"). It works the same
+// way regardless of which Transport backs t.
+func identifyMonitor(t Transport) (*edid.EDID, string) {
+	raw, err := t.ReadEDID()
 	if err != nil {
-		return 0, err
-	}
-	defer f.Close()
-
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2C_SLAVE, uintptr(DDC_ADDR)); errno != 0 {
-		return 0, errno
+		return nil, ""
 	}
-
-	req := []byte{0x51, 0x82, 0x01, vcp}
-	req = append(req, getChecksum(req))
-
-	if _, err := f.Write(req); err != nil {
-		return 0, err
-	}
-
-	for retry := 0; retry < 2; retry++ {
-		time.Sleep(150 * time.Millisecond)
-		reply := make([]byte, 16)
-		n, err := f.Read(reply)
-		if err == nil && n >= 10 {
-			for i := 0; i < n-5; i++ {
-				if reply[i] == 0x02 && reply[i+2] == vcp {
-					return uint16(reply[i+6])<<8 | uint16(reply[i+7]), nil
-				}
-			}
-			if reply[0] == 0x6e {
-				return uint16(reply[8])<<8 | uint16(reply[9]), nil
-			}
-		}
-	}
-	return 0, fmt.Errorf("failed read")
+	return parseEDIDBytes(raw)
 }
 
-func getMonitorName(bus string) string {
-	f, err := os.OpenFile(bus, os.O_RDWR, 0)
-	if err != nil {
-		return ""
-	}
-	defer f.Close()
-
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2C_SLAVE, uintptr(EDID_ADDR)); errno != 0 {
-		return ""
-	}
-
-	edid := make([]byte, 128)
-	_, err = f.Read(edid)
+func parseEDIDBytes(raw []byte) (*edid.EDID, string) {
+	e, err := edid.Parse(raw)
 	if err != nil {
-		return ""
-	}
-
-	name := ""
-	for j := 54; j < 108; j += 18 {
-		if edid[j] == 0 && edid[j+1] == 0 && edid[j+2] == 0 && edid[j+3] == 0xfc {
-			name = strings.TrimSpace(string(edid[j+5 : j+18]))
-			break
-		}
+		logVerbose("EDID parse failed: %v", err)
+		return nil, ""
 	}
 
+	name := e.Name
 	if name == "" {
-		if bytes.Contains(edid, []byte("U4021QW")) {
-			name = "Dell U4021QW"
-		} else if bytes.Contains(edid, []byte("DELL")) {
-			name = "DELL Monitor"
-		}
+		name = fmt.Sprintf("%s Monitor", e.ManufacturerID)
 	}
-	return name
+	return e, name
 }
 
 func findConfigFile(customPath string) (string, error) {
@@ -209,40 +179,237 @@ func loadConfigs(path string) ([]MonitorConfig, error) {
 	return configs, err
 }
 
-func discoverDevices(configs []MonitorConfig) []Device {
+func discoverI2CDevices(configs []MonitorConfig) []Device {
 	var devices []Device
 	matches, _ := filepath.Glob("/dev/i2c-*")
 	for _, bus := range matches {
-		name := getMonitorName(bus)
+		transport := NewI2CTransport(bus)
+		e, name := identifyMonitor(transport)
 		if name == "" {
+			transport.Close()
 			continue
 		}
 
 		var matchedConfig *MonitorConfig
 		for i := range configs {
-			if strings.Contains(strings.ToUpper(name), strings.ToUpper(configs[i].Match)) {
+			if configs[i].Match.Matches(e, name) {
 				matchedConfig = &configs[i]
 				break
 			}
 		}
-		devices = append(devices, Device{Bus: bus, Name: name, Config: matchedConfig})
+		devices = append(devices, Device{Bus: bus, Name: name, Config: matchedConfig, Transport: transport, EDID: e})
 	}
 	return devices
 }
 
-func applyFeature(target *Device, featureName, valueLabel string) error {
+func discoverDevices(configs []MonitorConfig) []Device {
+	devices := discoverI2CDevices(configs)
+	devices = append(devices, discoverUSBDevices(configs)...)
+	return devices
+}
+
+// matchedDevices returns a pointer to every device in devices that has a
+// matched config, for callers that want to operate on all known monitors
+// at once (e.g. --all).
+func matchedDevices(devices []Device) []*Device {
+	var targets []*Device
+	for i := range devices {
+		if devices[i].Config != nil {
+			targets = append(targets, &devices[i])
+		}
+	}
+	return targets
+}
+
+// resolveFeatureValue looks up featureName/valueLabel in target's config and
+// decodes them to the raw VCP opcode and value applyFeature(Ctx) write.
+func resolveFeatureValue(target *Device, featureName, valueLabel string) (byte, uint16, error) {
 	if target.Config == nil {
-		return fmt.Errorf("no config for this monitor")
+		return 0, 0, fmt.Errorf("no config for this monitor")
 	}
 	feat, ok := target.Config.Features[featureName]
 	if !ok {
-		return fmt.Errorf("feature %s not defined", featureName)
+		return 0, 0, fmt.Errorf("feature %s not defined", featureName)
 	}
 	valStr, ok := feat.Values[strings.ToLower(valueLabel)]
 	if !ok {
-		return fmt.Errorf("invalid value %s for %s. Options: %v", valueLabel, featureName, feat.Values)
+		return 0, 0, fmt.Errorf("invalid value %s for %s. Options: %v", valueLabel, featureName, feat.Values)
+	}
+
+	vcp, err := parseHex(feat.VCP)
+	if err != nil {
+		return 0, 0, fmt.Errorf("feature %s: %w", featureName, err)
+	}
+	value, err := parseHex16(valStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("feature %s value %s: %w", featureName, valueLabel, err)
+	}
+	return vcp, value, nil
+}
+
+func applyFeature(target *Device, featureName, valueLabel string) error {
+	vcp, value, err := resolveFeatureValue(target, featureName, valueLabel)
+	if err != nil {
+		return err
+	}
+	return target.Transport.SetVCP(vcp, value)
+}
+
+// applyFeatureCtx behaves like applyFeature, but drives the underlying call
+// through ContextTransport.SetVCPCtx when the transport supports it, so a
+// caller can bound how long one device is allowed to stall the others.
+func applyFeatureCtx(ctx context.Context, target *Device, featureName, valueLabel string) error {
+	vcp, value, err := resolveFeatureValue(target, featureName, valueLabel)
+	if err != nil {
+		return err
+	}
+	if ct, ok := target.Transport.(ContextTransport); ok {
+		return ct.SetVCPCtx(ctx, vcp, value)
+	}
+	return target.Transport.SetVCP(vcp, value)
+}
+
+// ApplyFeatureConcurrent applies featureName/valueLabel to every device in
+// targets at once, rather than one after another, so a preset touching N
+// monitors takes roughly as long as the slowest single monitor instead of
+// N times longer. The returned slice is index-aligned with targets.
+func ApplyFeatureConcurrent(ctx context.Context, targets []*Device, featureName, valueLabel string) []error {
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target *Device) {
+			defer wg.Done()
+			errs[i] = applyFeatureCtx(ctx, target, featureName, valueLabel)
+		}(i, target)
+	}
+	wg.Wait()
+	return errs
+}
+
+// applyPreset applies preset's features to target in the same robust order
+// the --preset handling in main used to inline: PBP is reset before switching the main
+// input to guarantee a clean transition, rather than changing PBP state and
+// input source simultaneously.
+func applyPreset(ctx context.Context, target *Device, presetName string, preset map[string]string) error {
+	logVerbose("Applying preset: %s on %s", presetName, target.Bus)
+
+	var order []string
+	targetPbpMode := preset["pbp_mode"]
+
+	if targetPbpMode == "off" {
+		order = []string{"pbp_mode", "input_source", "usb_selection"}
+	} else {
+		logVerbose("Resetting PBP before applying preset to ensure clean transition...")
+		if err := applyFeatureCtx(ctx, target, "pbp_mode", "off"); err != nil {
+			logVerbose("Reset of pbp_mode failed: %v", err)
+		}
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			return err
+		}
+		order = []string{"input_source", "pbp_mode", "pbp_sub_input", "usb_selection"}
+	}
+
+	var firstErr error
+	for _, featName := range order {
+		valLabel, ok := preset[featName]
+		if !ok {
+			continue
+		}
+		if err := applyFeatureCtx(ctx, target, featName, valLabel); err != nil {
+			logVerbose("Error applying %s on %s: %v", featName, target.Bus, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if featName == "pbp_mode" || featName == "input_source" {
+			if err := sleepCtx(ctx, 2*time.Second); err != nil {
+				return err
+			}
+		} else if err := sleepCtx(ctx, 1*time.Second); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// tryDaemonClient dials a running daemon and, if reachable, performs the
+// preset/input/pbp action requested through it instead of opening
+// /dev/i2c-* directly. If no --bus was given, it asks the daemon to resolve
+// the same default target main() would otherwise pick itself, so the common
+// hotkey-daemon case (--input/--pbp with no --bus) still gets routed through
+// the daemon instead of falling through to full rediscovery. It reports true
+// if the daemon handled the request (successfully or not) and the caller
+// should stop; false means no daemon is reachable (or it has nothing to
+// resolve a default target from) and the caller should fall back to the
+// direct path.
+func tryDaemonClient(bus, preset, input, pbp string) bool {
+	if preset == "" && input == "" && pbp == "" {
+		return false
+	}
+	conn, ok := dialDaemon()
+	if !ok {
+		return false
+	}
+	defer conn.Close()
+
+	if preset != "" {
+		if _, err := conn.call(dbusServiceName, dbusObjectPath, dbusInterfaceName, "ApplyPreset", "s", []string{preset}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying preset via daemon: %v\n", err)
+		} else {
+			fmt.Printf("Applied preset %s via daemon\n", preset)
+		}
+		return true
+	}
+
+	if bus == "" {
+		resolved, ok := resolveDaemonDefaultBus(conn)
+		if !ok {
+			return false
+		}
+		bus = resolved
+	}
+	if input != "" {
+		if _, err := conn.call(dbusServiceName, dbusObjectPath, dbusInterfaceName, "SetFeature", "sss", []string{bus, "input_source", input}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting input_source via daemon: %v\n", err)
+		}
+	}
+	if pbp != "" {
+		if _, err := conn.call(dbusServiceName, dbusObjectPath, dbusInterfaceName, "SetFeature", "sss", []string{bus, "pbp_mode", pbp}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting pbp_mode via daemon: %v\n", err)
+		}
 	}
-	return setVCP(target.Bus, parseHex(feat.VCP), parseHex16(valStr))
+	return true
+}
+
+// resolveDaemonDefaultBus asks the daemon which monitors it knows about and
+// picks the same default target main() itself falls back to for --input/
+// --pbp given no --bus: the first one with a matched config, or else just
+// the first monitor at all. It reports ok=false if the daemon has nothing
+// to offer, so the caller falls back to the direct discovery path.
+func resolveDaemonDefaultBus(conn *dbusConn) (bus string, ok bool) {
+	reply, err := conn.call(dbusServiceName, dbusObjectPath, dbusInterfaceName, "ListMonitors", "", nil)
+	if err != nil || len(reply) == 0 || reply[0] == "" {
+		return "", false
+	}
+
+	var fallback string
+	for _, entry := range strings.Split(reply[0], "\x1f") {
+		parts := strings.SplitN(entry, "|", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if fallback == "" {
+			fallback = parts[0]
+		}
+		if parts[2] != "" {
+			return parts[0], true
+		}
+	}
+	if fallback != "" {
+		return fallback, true
+	}
+	return "", false
 }
 
 func main() {
@@ -252,7 +419,11 @@ func main() {
 	inputPtr := flag.String("input", "", "Switch input source")
 	pbpPtr := flag.String("pbp", "", "Set PBP mode")
 	presetPtr := flag.String("preset", "", "Apply a named preset")
+	allPtr := flag.Bool("all", false, "Apply --input/--pbp/--preset to every matched monitor concurrently")
+	daemonPtr := flag.Bool("daemon", false, "Run as a background daemon exposing org.spdg.DellMonitor on the session bus")
 	scanPtr := flag.Bool("scan", false, "Scan VCP codes E0-F2")
+	generateConfigPtr := flag.Bool("generate-config", false, "Discover monitors and print a generated monitors.json")
+	outputPtr := flag.String("output", "", "Output path for --generate-config (default: stdout)")
 	flag.BoolVar(&verbose, "verbose", false, "Verbose output")
 
 	flag.Parse()
@@ -262,6 +433,14 @@ func main() {
 		return
 	}
 
+	if *generateConfigPtr {
+		if err := runGenerateConfig(*outputPtr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating config: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	actualConfigPath, err := findConfigFile(*configPath)
 	var configs []MonitorConfig
 	if err == nil {
@@ -272,6 +451,18 @@ func main() {
 		}
 	}
 
+	if *daemonPtr {
+		if err := runDaemon(actualConfigPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running daemon: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !*allPtr && tryDaemonClient(*busPtr, *presetPtr, *inputPtr, *pbpPtr) {
+		return
+	}
+
 	devices := discoverDevices(configs)
 
 	var target *Device
@@ -283,7 +474,12 @@ func main() {
 			}
 		}
 		if target == nil {
-			target = &Device{Bus: *busPtr, Name: "Manual"}
+			transport, err := newTransport(*busPtr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", *busPtr, err)
+				os.Exit(1)
+			}
+			target = &Device{Bus: *busPtr, Name: "Manual", Transport: transport}
 			if len(configs) > 0 {
 				target.Config = &configs[0]
 			}
@@ -308,10 +504,55 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *allPtr && (*inputPtr != "" || *pbpPtr != "" || *presetPtr != "") {
+		targets := matchedDevices(devices)
+		if len(targets) == 0 {
+			fmt.Fprintln(os.Stderr, "No matched monitors to apply to.")
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+		if *presetPtr != "" {
+			var wg sync.WaitGroup
+			for _, t := range targets {
+				wg.Add(1)
+				go func(t *Device) {
+					defer wg.Done()
+					preset, ok := t.Config.Presets[*presetPtr]
+					if !ok {
+						fmt.Fprintf(os.Stderr, "%s: preset %s not found\n", t.Bus, *presetPtr)
+						return
+					}
+					if err := applyPreset(ctx, t, *presetPtr, preset); err != nil {
+						fmt.Fprintf(os.Stderr, "%s: error applying preset: %v\n", t.Bus, err)
+					}
+				}(t)
+			}
+			wg.Wait()
+			return
+		}
+
+		if *inputPtr != "" {
+			for i, err := range ApplyFeatureConcurrent(ctx, targets, "input_source", *inputPtr) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: error applying input_source: %v\n", targets[i].Bus, err)
+				}
+			}
+		}
+		if *pbpPtr != "" {
+			for i, err := range ApplyFeatureConcurrent(ctx, targets, "pbp_mode", *pbpPtr) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: error applying pbp_mode: %v\n", targets[i].Bus, err)
+				}
+			}
+		}
+		return
+	}
+
 	if *scanPtr {
 		fmt.Printf("Scanning VCP codes E0-F2 on %s...\n", target.Bus)
 		for code := 0xE0; code <= 0xF2; code++ {
-			val, err := getVCP(target.Bus, byte(code))
+			val, err := target.Transport.GetVCP(byte(code))
 			if err == nil {
 				fmt.Printf("VCP 0x%02X: 0x%04X\n", code, val)
 			}
@@ -331,33 +572,8 @@ func main() {
 		}
 
 		fmt.Printf("Applying preset: %s on %s\n", *presetPtr, target.Bus)
-
-		// Determine robust application order
-		var order []string
-		targetPbpMode := preset["pbp_mode"]
-
-		if targetPbpMode == "off" {
-			// To Fullscreen: PBP OFF -> Main Input -> USB
-			order = []string{"pbp_mode", "input_source", "usb_selection"}
-		} else {
-			// To PBP: PBP OFF (Reset) -> Main Input -> PBP ON -> Sub Input -> USB
-			logVerbose("Resetting PBP before applying preset to ensure clean transition...")
-			applyFeature(target, "pbp_mode", "off")
-			time.Sleep(2 * time.Second)
-			order = []string{"input_source", "pbp_mode", "pbp_sub_input", "usb_selection"}
-		}
-
-		for _, featName := range order {
-			if valLabel, ok := preset[featName]; ok {
-				if err := applyFeature(target, featName, valLabel); err != nil {
-					fmt.Fprintf(os.Stderr, "Error applying %s: %v\n", featName, err)
-				}
-				if featName == "pbp_mode" || featName == "input_source" {
-					time.Sleep(2 * time.Second)
-				} else {
-					time.Sleep(1 * time.Second)
-				}
-			}
+		if err := applyPreset(context.Background(), target, *presetPtr, preset); err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying preset: %v\n", err)
 		}
 	}
 
@@ -370,9 +586,17 @@ func main() {
 
 	if *statusPtr || (*inputPtr == "" && *pbpPtr == "" && *presetPtr == "" && !*scanPtr) {
 		fmt.Printf("--- Status for %s (%s) ---\n", target.Bus, target.Name)
+		if target.EDID != nil {
+			fmt.Printf("Manufacturer: %s  Product: 0x%04X  Serial: %d\n", target.EDID.ManufacturerID, target.EDID.ProductCode, target.EDID.SerialNumber)
+		}
 		if target.Config != nil {
 			for name, feat := range target.Config.Features {
-				v, _ := getVCP(target.Bus, parseHex(feat.VCP))
+				vcp, err := parseHex(feat.VCP)
+				if err != nil {
+					logVerbose("skipping %s: %v", name, err)
+					continue
+				}
+				v, _ := target.Transport.GetVCP(vcp)
 				fmt.Printf("%s: 0x%04X\n", name, v)
 			}
 		}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+
+	"github.com/SPDG/dell-monitor-tool/edid"
 )
 
 func TestLoadConfigs(t *testing.T) {
@@ -17,7 +19,7 @@ func TestLoadConfigs(t *testing.T) {
 	testConfigs := []MonitorConfig{
 		{
 			Model: "Test Monitor",
-			Match: "TEST-123",
+			Match: MatchCriteria{NameContains: "TEST-123"},
 			Features: map[string]FeatureConfig{
 				"brightness": {VCP: "0x10", Values: map[string]string{"high": "100"}},
 			},
@@ -34,7 +36,7 @@ func TestLoadConfigs(t *testing.T) {
 		t.Fatalf("Failed to load configs: %v", err)
 	}
 
-	if len(configs) != 1 || configs[0].Match != "TEST-123" {
+	if len(configs) != 1 || configs[0].Match.NameContains != "TEST-123" {
 		t.Errorf("Unexpected config loaded: %+v", configs)
 	}
 }
@@ -95,3 +97,30 @@ func TestParseHex16(t *testing.T) {
 		t.Error("parseHex16(invalid) should have returned an error")
 	}
 }
+
+func TestMatchCriteriaMatches(t *testing.T) {
+	e := &edid.EDID{ManufacturerID: "DEL", ProductCode: 0x9999}
+
+	cases := []struct {
+		name  string
+		crit  MatchCriteria
+		edid  *edid.EDID
+		label string
+		want  bool
+	}{
+		{"empty criteria never matches", MatchCriteria{}, e, "Dell U4021QW", false},
+		{"pnp id matches", MatchCriteria{PNPID: "del"}, e, "Dell U4021QW", true},
+		{"pnp id mismatch", MatchCriteria{PNPID: "ACI"}, e, "Dell U4021QW", false},
+		{"product code matches", MatchCriteria{ProductCode: 0x9999}, e, "Dell U4021QW", true},
+		{"name substring matches", MatchCriteria{NameContains: "u4021qw"}, e, "Dell U4021QW", true},
+		{"combined criteria all must match", MatchCriteria{PNPID: "DEL", ProductCode: 0x1234}, e, "Dell U4021QW", false},
+		{"no edid falls back to name only", MatchCriteria{NameContains: "DELL"}, nil, "DELL Monitor", true},
+		{"no edid fails pnp match", MatchCriteria{PNPID: "DEL"}, nil, "DELL Monitor", false},
+	}
+
+	for _, c := range cases {
+		if got := c.crit.Matches(c.edid, c.label); got != c.want {
+			t.Errorf("%s: Matches() = %v; want %v", c.name, got, c.want)
+		}
+	}
+}
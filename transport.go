@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Transport abstracts how this tool talks DDC/CI to a monitor, so the rest
+// of the code doesn't care whether that's over /dev/i2c-* or a monitor's
+// USB HID hub.
+type Transport interface {
+	GetVCP(vcp byte) (uint16, error)
+	SetVCP(vcp byte, value uint16) error
+	ReadEDID() ([]byte, error)
+	Close() error
+}
+
+// ContextTransport is implemented by transports that can honour a
+// deadline/cancellation instead of blocking for their full retry budget,
+// which lets multiple devices be driven concurrently.
+type ContextTransport interface {
+	Transport
+	GetVCPCtx(ctx context.Context, vcp byte) (uint16, error)
+	SetVCPCtx(ctx context.Context, vcp byte, value uint16) error
+}
+
+// I2CTransport speaks DDC/CI over /dev/i2c-*. It is the default transport,
+// and lazily opens a single long-lived Bus on first use rather than
+// reopening the device file on every call.
+type I2CTransport struct {
+	path string
+	mu   sync.Mutex
+	bus  *Bus
+}
+
+func NewI2CTransport(path string) *I2CTransport {
+	return &I2CTransport{path: path}
+}
+
+func (t *I2CTransport) ensureBus() (*Bus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bus == nil {
+		b, err := OpenBus(t.path)
+		if err != nil {
+			return nil, err
+		}
+		t.bus = b
+	}
+	return t.bus, nil
+}
+
+func (t *I2CTransport) SetVCP(vcp byte, value uint16) error {
+	return t.SetVCPCtx(context.Background(), vcp, value)
+}
+
+func (t *I2CTransport) GetVCP(vcp byte) (uint16, error) {
+	return t.GetVCPCtx(context.Background(), vcp)
+}
+
+func (t *I2CTransport) SetVCPCtx(ctx context.Context, vcp byte, value uint16) error {
+	b, err := t.ensureBus()
+	if err != nil {
+		return err
+	}
+	return b.SetVCPCtx(ctx, vcp, value)
+}
+
+func (t *I2CTransport) GetVCPCtx(ctx context.Context, vcp byte) (uint16, error) {
+	b, err := t.ensureBus()
+	if err != nil {
+		return 0, err
+	}
+	return b.GetVCPCtx(ctx, vcp)
+}
+
+func (t *I2CTransport) ReadEDID() ([]byte, error) {
+	b, err := t.ensureBus()
+	if err != nil {
+		return nil, err
+	}
+	return b.ReadEDID()
+}
+
+func (t *I2CTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bus == nil {
+		return nil
+	}
+	err := t.bus.Close()
+	t.bus = nil
+	return err
+}
+
+// USB HID report IDs used by Dell's DDC/CI-over-USB passthrough. These are
+// vendor-specific. Discovery does not probe for them: a /dev/hidraw* device
+// whose VID/PID matches a config entry is listed even if it never answers,
+// named after that entry's Model.
+const (
+	usbDDCReportID  = 0x01
+	usbEDIDReportID = 0x02
+)
+
+// USBHIDTransport speaks the same DDC/CI VCP payloads as I2CTransport, but
+// wrapped in a HID feature report over /dev/hidraw* instead of raw I2C, for
+// monitors whose DDC lines aren't reachable but whose internal USB hub is.
+type USBHIDTransport struct {
+	path string
+	f    *os.File
+}
+
+func NewUSBHIDTransport(path string) (*USBHIDTransport, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &USBHIDTransport{path: path, f: f}, nil
+}
+
+func (t *USBHIDTransport) setFeatureReport(reportID byte, payload []byte) error {
+	report := make([]byte, len(payload)+1)
+	report[0] = reportID
+	copy(report[1:], payload)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, t.f.Fd(), hidiocSetFeature(len(report)), uintptr(unsafe.Pointer(&report[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (t *USBHIDTransport) getFeatureReport(reportID byte, size int) ([]byte, error) {
+	report := make([]byte, size+1)
+	report[0] = reportID
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, t.f.Fd(), hidiocGetFeature(len(report)), uintptr(unsafe.Pointer(&report[0])))
+	if errno != 0 {
+		return nil, errno
+	}
+	return report[1:], nil
+}
+
+func (t *USBHIDTransport) SetVCP(vcp byte, value uint16) error {
+	data := []byte{0x51, 0x84, 0x03, vcp, byte(value >> 8), byte(value & 0xFF)}
+	data = append(data, getChecksum(data))
+	return t.setFeatureReport(usbDDCReportID, data)
+}
+
+func (t *USBHIDTransport) GetVCP(vcp byte) (uint16, error) {
+	req := []byte{0x51, 0x82, 0x01, vcp}
+	req = append(req, getChecksum(req))
+	if err := t.setFeatureReport(usbDDCReportID, req); err != nil {
+		return 0, err
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	reply, err := t.getFeatureReport(usbDDCReportID, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < len(reply)-5; i++ {
+		if reply[i] == 0x02 && reply[i+2] == vcp {
+			return uint16(reply[i+6])<<8 | uint16(reply[i+7]), nil
+		}
+	}
+	if len(reply) >= 10 && reply[0] == 0x6e {
+		return uint16(reply[8])<<8 | uint16(reply[9]), nil
+	}
+	return 0, fmt.Errorf("failed read")
+}
+
+func (t *USBHIDTransport) ReadEDID() ([]byte, error) {
+	return t.getFeatureReport(usbEDIDReportID, 128)
+}
+
+func (t *USBHIDTransport) Close() error {
+	return t.f.Close()
+}
+
+// Linux ioctl direction/size/type/nr encoding, used to build the
+// HIDIOCGFEATURE(len)/HIDIOCSFEATURE(len) request codes (see
+// <linux/hid.h>) without pulling in a hidraw-specific dependency.
+const (
+	iocNrBits   = 8
+	iocTypeBits = 8
+	iocSizeBits = 14
+
+	iocNrShift   = 0
+	iocTypeShift = iocNrShift + iocNrBits
+	iocSizeShift = iocTypeShift + iocTypeBits
+	iocDirShift  = iocSizeShift + iocSizeBits
+
+	iocWrite = 1
+	iocRead  = 2
+)
+
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return (dir << iocDirShift) | (typ << iocTypeShift) | (nr << iocNrShift) | (size << iocSizeShift)
+}
+
+func hidiocGetFeature(size int) uintptr {
+	return ioc(iocWrite|iocRead, 'H', 0x07, uintptr(size))
+}
+
+func hidiocSetFeature(size int) uintptr {
+	return ioc(iocWrite|iocRead, 'H', 0x06, uintptr(size))
+}
+
+// newTransport picks a Transport based on the device path: /dev/hidraw*
+// devices speak USB HID DDC/CI, everything else is assumed to be an
+// /dev/i2c-* bus.
+func newTransport(path string) (Transport, error) {
+	if strings.HasPrefix(path, "/dev/hidraw") {
+		return NewUSBHIDTransport(path)
+	}
+	return NewI2CTransport(path), nil
+}
+
+// hidrawVIDPID reads the USB vendor/product ID of a /dev/hidraw* device
+// from its sysfs uevent, e.g. HID_ID=0003:000010AC:00009999.
+func hidrawVIDPID(path string) (uint16, uint16, error) {
+	ueventPath := filepath.Join("/sys/class/hidraw", filepath.Base(path), "device/uevent")
+	data, err := os.ReadFile(ueventPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "HID_ID=") {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(line, "HID_ID="), ":")
+		if len(parts) != 3 {
+			continue
+		}
+		vid, err := parseHex16(last4(parts[1]))
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing vendor id in %s: %w", ueventPath, err)
+		}
+		pid, err := parseHex16(last4(parts[2]))
+		if err != nil {
+			return 0, 0, fmt.Errorf("parsing product id in %s: %w", ueventPath, err)
+		}
+		return vid, pid, nil
+	}
+	return 0, 0, fmt.Errorf("HID_ID not found in %s", ueventPath)
+}
+
+func last4(s string) string {
+	if len(s) <= 4 {
+		return s
+	}
+	return s[len(s)-4:]
+}
+
+// discoverUSBDevices enumerates /dev/hidraw* devices and matches their USB
+// VID/PID against any config entry that declares one, for monitors reached
+// over their built-in USB hub instead of i2c-dev.
+func discoverUSBDevices(configs []MonitorConfig) []Device {
+	var devices []Device
+	matches, _ := filepath.Glob("/dev/hidraw*")
+	for _, path := range matches {
+		vid, pid, err := hidrawVIDPID(path)
+		if err != nil {
+			continue
+		}
+
+		for i := range configs {
+			if configs[i].USB == nil || configs[i].USB.VendorID != vid || configs[i].USB.ProductID != pid {
+				continue
+			}
+			transport, err := NewUSBHIDTransport(path)
+			if err != nil {
+				logVerbose("Skipping %s: %v", path, err)
+				break
+			}
+			e, name := identifyMonitor(transport)
+			if name == "" {
+				name = configs[i].Model
+			}
+			devices = append(devices, Device{Bus: path, Name: name, Config: &configs[i], Transport: transport, EDID: e})
+			break
+		}
+	}
+	return devices
+}
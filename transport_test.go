@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestNewTransport(t *testing.T) {
+	if _, ok := mustTransport(t, "/dev/i2c-3").(*I2CTransport); !ok {
+		t.Error("expected /dev/i2c-3 to resolve to an I2CTransport")
+	}
+}
+
+func mustTransport(t *testing.T, path string) Transport {
+	t.Helper()
+	tr, err := newTransport(path)
+	if err != nil {
+		t.Fatalf("newTransport(%s) returned error: %v", path, err)
+	}
+	return tr
+}
+
+func TestLast4(t *testing.T) {
+	cases := []struct{ input, want string }{
+		{"000010AC", "10AC"},
+		{"ABCD", "ABCD"},
+		{"CD", "CD"},
+	}
+	for _, c := range cases {
+		if got := last4(c.input); got != c.want {
+			t.Errorf("last4(%s) = %s; want %s", c.input, got, c.want)
+		}
+	}
+}
+
+func TestHidiocFeatureCodes(t *testing.T) {
+	// HIDIOCGFEATURE(len) and HIDIOCSFEATURE(len) must differ so a get
+	// can never silently perform a set.
+	if hidiocGetFeature(9) == hidiocSetFeature(9) {
+		t.Error("HIDIOCGFEATURE and HIDIOCSFEATURE must not collide")
+	}
+}